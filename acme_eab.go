@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+)
+
+// newACMEClient builds an acme.Client for cfg.ACMEDirectoryURL. An empty
+// ACMEDirectoryURL falls back to Let's Encrypt's production directory.
+func newACMEClient(cfg *Config) *acme.Client {
+	directoryURL := cfg.ACMEDirectoryURL
+	if directoryURL == "" {
+		directoryURL = acme.LetsEncryptURL
+	}
+	return &acme.Client{DirectoryURL: directoryURL}
+}
+
+// registerWithEAB creates a new ACME account at cfg.ACMEDirectoryURL,
+// binding it to the external account identified by cfg.ACMEEABKeyID and
+// cfg.ACMEEABHMACKey. This is what lets nitriding integrate with CAs that
+// require EAB instead of (or in addition to) public identity verification,
+// such as internal-PKI issuers (step-ca, Vault PKI, EJBCA).
+//
+// golang.org/x/crypto/acme has no support for External Account Binding --
+// its Account type carries no such field, and Client.Register has no way to
+// attach one -- so unlike the rest of our ACME flow, account creation here
+// is a hand-rolled JWS-signed POST to the CA's newAccount endpoint, as
+// defined by RFC 8555 ยง7.3.
+func registerWithEAB(ctx context.Context, cfg *Config) (*acme.Client, *acme.Account, error) {
+	client := newACMEClient(cfg)
+
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ACME account key: %w", err)
+	}
+	client.Key = accountKey
+
+	dir, err := client.Discover(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to discover ACME directory: %w", err)
+	}
+
+	eabJWS, err := signEAB(cfg.ACMEEABKeyID, cfg.ACMEEABHMACKey, accountKey.Public(), dir.RegURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build EAB JWS: %w", err)
+	}
+
+	account, err := newAccountWithEAB(ctx, accountKey, dir.NonceURL, dir.RegURL, eabJWS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to register ACME account with EAB: %w", err)
+	}
+	return client, account, nil
+}
+
+// acmeNewAccountRequest is the RFC 8555 ยง7.3 newAccount request body.
+type acmeNewAccountRequest struct {
+	TermsOfServiceAgreed   bool            `json:"termsOfServiceAgreed"`
+	ExternalAccountBinding json.RawMessage `json:"externalAccountBinding"`
+}
+
+// acmeAccountResponse is the subset of RFC 8555 ยง7.1.2's account object that
+// we care about.
+type acmeAccountResponse struct {
+	Status string `json:"status"`
+}
+
+// newAccountWithEAB POSTs a JWS-signed RFC 8555 ยง7.3 newAccount request,
+// carrying eabJWS as its externalAccountBinding, and returns the resulting
+// account.
+func newAccountWithEAB(ctx context.Context, accountKey *ecdsa.PrivateKey, nonceURL, newAccountURL string, eabJWS []byte) (*acme.Account, error) {
+	nonce, err := fetchNonce(ctx, nonceURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch replay nonce: %w", err)
+	}
+
+	payload, err := json.Marshal(acmeNewAccountRequest{
+		TermsOfServiceAgreed:   true,
+		ExternalAccountBinding: eabJWS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal newAccount request: %w", err)
+	}
+
+	jws, err := signOuterJWS(accountKey, nonce, newAccountURL, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign newAccount JWS: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, newAccountURL, bytes.NewReader(jws))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/jose+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("newAccount request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read newAccount response: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CA rejected newAccount request with status %s: %s", resp.Status, raw)
+	}
+
+	var parsed acmeAccountResponse
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal newAccount response: %w", err)
+	}
+	return &acme.Account{URI: resp.Header.Get("Location"), Status: parsed.Status}, nil
+}
+
+// fetchNonce retrieves a fresh replay nonce from the CA's newNonce endpoint,
+// as required before signing any ACME JWS (RFC 8555 ยง7.2).
+func fetchNonce(ctx context.Context, nonceURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, nonceURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", fmt.Errorf("CA did not return a Replay-Nonce header")
+	}
+	return nonce, nil
+}
+
+// signOuterJWS builds the RFC 8555 ยง6.2 JWS that wraps every ACME request
+// made before an account exists: its protected header carries the account
+// key's own JWK (there's no kid yet) along with the nonce and target URL,
+// and it's signed with the account key itself using ES256.
+func signOuterJWS(accountKey *ecdsa.PrivateKey, nonce, url string, payload []byte) ([]byte, error) {
+	jwk, err := jwkFromPublicKey(accountKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	header := struct {
+		Alg   string            `json:"alg"`
+		JWK   map[string]string `json:"jwk"`
+		Nonce string            `json:"nonce"`
+		URL   string            `json:"url"`
+	}{"ES256", jwk, nonce, url}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := base64.RawURLEncoding.EncodeToString(headerJSON)
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+
+	sig, err := signES256(accountKey, protected+"."+encodedPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}{protected, encodedPayload, base64.RawURLEncoding.EncodeToString(sig)})
+}
+
+// signES256 signs signingInput with accountKey and returns the fixed-size
+// r||s encoding that RFC 7518 ยง3.4 (JWS ES256) requires, as opposed to
+// crypto/ecdsa's variable-length ASN.1 DER encoding.
+func signES256(accountKey *ecdsa.PrivateKey, signingInput string) ([]byte, error) {
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, accountKey, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign JWS: %w", err)
+	}
+	size := coordinateSize(accountKey.Curve)
+	return append(padCoordinate(r, size), padCoordinate(s, size)...), nil
+}
+
+// signEAB builds the External Account Binding JWS described in RFC 8555
+// ยง7.3.4: its protected header carries the EAB key ID and target URL, its
+// payload is the ACME account's outer JWK, and it's signed with HS256 over
+// the EAB HMAC key -- not with the account key itself.
+func signEAB(keyID string, hmacKey []byte, accountKey crypto.PublicKey, url string) ([]byte, error) {
+	jwk, err := jwkFromPublicKey(accountKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := map[string]string{
+		"alg": "HS256",
+		"kid": keyID,
+		"url": url,
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	payloadJSON, err := json.Marshal(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := base64.RawURLEncoding.EncodeToString(headerJSON)
+	payload := base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write([]byte(protected + "." + payload))
+	signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return json.Marshal(struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}{protected, payload, signature})
+}
+
+// jwkFromPublicKey converts an ECDSA P-256 public key into the JWK form that
+// RFC 8555's EAB payload requires. Per RFC 7518 ยง6.2.1.2, x and y must be
+// the fixed-size, zero-padded octet encoding of the curve's coordinates --
+// big.Int.Bytes() alone would silently drop a leading zero byte whenever a
+// coordinate happens to have one.
+func jwkFromPublicKey(pub crypto.PublicKey) (map[string]string, error) {
+	ecdsaPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported ACME account key type %T", pub)
+	}
+	size := coordinateSize(ecdsaPub.Curve)
+	return map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(padCoordinate(ecdsaPub.X, size)),
+		"y":   base64.RawURLEncoding.EncodeToString(padCoordinate(ecdsaPub.Y, size)),
+	}, nil
+}
+
+// coordinateSize returns the fixed byte length of curve's field elements.
+func coordinateSize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+// padCoordinate left-pads v's big-endian encoding with zero bytes to size.
+func padCoordinate(v *big.Int, size int) []byte {
+	out := make([]byte, size)
+	v.FillBytes(out)
+	return out
+}
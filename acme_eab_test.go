@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/acme"
+)
+
+func TestSignEABProducesValidJWS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	raw, err := signEAB("kid-123", []byte("hmac-secret"), key.Public(), "https://ca.example.com/acme/new-account")
+	if err != nil {
+		t.Fatalf("failed to sign EAB: %s", err)
+	}
+
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(raw, &jws); err != nil {
+		t.Fatalf("failed to unmarshal JWS: %s", err)
+	}
+	if jws.Protected == "" || jws.Payload == "" || jws.Signature == "" {
+		t.Fatal("expected all three JWS fields to be populated")
+	}
+}
+
+func TestJWKFromPublicKeyRejectsUnsupportedKeys(t *testing.T) {
+	if _, err := jwkFromPublicKey("not a key"); err == nil {
+		t.Fatal("expected an error for an unsupported public key type")
+	}
+}
+
+func TestJWKFromPublicKeyPadsCoordinatesWithLeadingZeroByte(t *testing.T) {
+	// A coordinate of 31 bytes (rather than P-256's fixed 32) would be
+	// silently produced by big.Int.Bytes() whenever the real coordinate has
+	// a leading zero byte. jwkFromPublicKey must always emit 32 bytes.
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(make([]byte, 31)),
+		Y:     new(big.Int).SetBytes(append([]byte{1}, make([]byte, 30)...)),
+	}
+
+	jwk, err := jwkFromPublicKey(key)
+	if err != nil {
+		t.Fatalf("failed to build JWK: %s", err)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk["x"])
+	if err != nil {
+		t.Fatalf("failed to decode x: %s", err)
+	}
+	if len(x) != 32 {
+		t.Fatalf("got x of length %d, want 32", len(x))
+	}
+
+	y, err := base64.RawURLEncoding.DecodeString(jwk["y"])
+	if err != nil {
+		t.Fatalf("failed to decode y: %s", err)
+	}
+	if len(y) != 32 {
+		t.Fatalf("got y of length %d, want 32", len(y))
+	}
+}
+
+func TestNewACMEClientFallsBackToLetsEncrypt(t *testing.T) {
+	client := newACMEClient(&Config{})
+	if client.DirectoryURL != acme.LetsEncryptURL {
+		t.Fatalf("got directory URL %q, want %q", client.DirectoryURL, acme.LetsEncryptURL)
+	}
+}
+
+func TestNewACMEClientUsesConfiguredDirectory(t *testing.T) {
+	const dir = "https://ca.example.com/acme/directory"
+	client := newACMEClient(&Config{ACMEDirectoryURL: dir})
+	if client.DirectoryURL != dir {
+		t.Fatalf("got directory URL %q, want %q", client.DirectoryURL, dir)
+	}
+}
+
+func TestNewAccountWithEABRegistersAgainstTheCA(t *testing.T) {
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate account key: %s", err)
+	}
+
+	var sawExternalAccountBinding bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/new-nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "test-nonce")
+	})
+	mux.HandleFunc("/new-account", func(w http.ResponseWriter, r *http.Request) {
+		var req acmeNewAccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("server failed to decode newAccount request: %s", err)
+		}
+		sawExternalAccountBinding = len(req.ExternalAccountBinding) > 0
+		w.Header().Set("Location", "https://ca.example.com/acme/acct/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(acmeAccountResponse{Status: "valid"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	eabJWS, err := signEAB("kid-123", []byte("hmac-secret"), accountKey.Public(), srv.URL+"/new-account")
+	if err != nil {
+		t.Fatalf("failed to sign EAB: %s", err)
+	}
+
+	account, err := newAccountWithEAB(context.Background(), accountKey, srv.URL+"/new-nonce", srv.URL+"/new-account", eabJWS)
+	if err != nil {
+		t.Fatalf("failed to register account: %s", err)
+	}
+	if account.Status != "valid" {
+		t.Fatalf("got status %q, want %q", account.Status, "valid")
+	}
+	if account.URI != "https://ca.example.com/acme/acct/1" {
+		t.Fatalf("got URI %q, want the Location header's value", account.URI)
+	}
+	if !sawExternalAccountBinding {
+		t.Fatal("expected the newAccount request to carry an externalAccountBinding")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+
+	"golang.org/x/crypto/acme"
+)
+
+// obtainACMECertificate drives an ACME issuance for cfg.FQDN, using the
+// dns-01 challenge. dns-01 is the only challenge type handled here:
+// nitriding's HTTP-01 and TLS-ALPN-01 challenges are already answered by the
+// enclave's own Internet-facing listener, but dns-01 needs provider-specific
+// wiring (see dns_provider.go) in exchange for unlocking wildcard
+// certificates, which the other two challenge types can't issue.
+//
+// If cfg.ACMEEABKeyID is set, the ACME account is registered with External
+// Account Binding (see acme_eab.go), as required by most internal-PKI CAs.
+// Otherwise a plain account is registered against cfg.ACMEDirectoryURL (or
+// Let's Encrypt's directory, if that's unset).
+//
+// It returns a PEM-encoded certificate chain and a PEM-encoded EC private
+// key, suitable for passing straight to enclaveKeys.setNitridingKeys.
+func obtainACMECertificate(ctx context.Context, cfg *Config) (certPEM, keyPEM []byte, err error) {
+	if cfg.ACMEChallenge != "dns-01" {
+		return nil, nil, fmt.Errorf("obtainACMECertificate only handles the dns-01 challenge; got %q", cfg.ACMEChallenge)
+	}
+
+	var client *acme.Client
+	if cfg.ACMEEABKeyID != "" {
+		client, _, err = registerWithEAB(ctx, cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to register ACME account via EAB: %w", err)
+		}
+	} else {
+		client = newACMEClient(cfg)
+		accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate ACME account key: %w", err)
+		}
+		client.Key = accountKey
+		if _, err := client.Register(ctx, &acme.Account{}, acme.AcceptTOS); err != nil {
+			return nil, nil, fmt.Errorf("failed to register ACME account: %w", err)
+		}
+	}
+
+	authz, err := client.Authorize(ctx, cfg.FQDN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start ACME authorization for %s: %w", cfg.FQDN, err)
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "dns-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return nil, nil, fmt.Errorf("ACME server offered no dns-01 challenge for %s", cfg.FQDN)
+	}
+
+	provider, err := newDNSProvider(ctx, cfg.ACMEDNSProvider, cfg.ACMEDNSProviderCreds)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build dns-01 provider %q: %w", cfg.ACMEDNSProvider, err)
+	}
+
+	keyAuth, err := client.DNS01ChallengeRecord(challenge.Token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compute dns-01 key authorization: %w", err)
+	}
+	if err := provider.Present(ctx, cfg.FQDN, challenge.Token, keyAuth); err != nil {
+		return nil, nil, fmt.Errorf("failed to publish dns-01 TXT record: %w", err)
+	}
+	defer func() {
+		if cleanupErr := provider.CleanUp(ctx, cfg.FQDN, challenge.Token, keyAuth); cleanupErr != nil {
+			elog.Printf("Failed to clean up dns-01 TXT record for %s: %s", cfg.FQDN, cleanupErr)
+		}
+	}()
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return nil, nil, fmt.Errorf("ACME server rejected dns-01 challenge response: %w", err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return nil, nil, fmt.Errorf("failed waiting for dns-01 authorization for %s: %w", cfg.FQDN, err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: cfg.FQDN},
+		DNSNames: []string{cfg.FQDN},
+	}, certKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build certificate request: %w", err)
+	}
+
+	der, _, err := client.CreateCert(ctx, csr, 0, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to finalize ACME certificate for %s: %w", cfg.FQDN, err)
+	}
+
+	var certBuf bytes.Buffer
+	for _, block := range der {
+		if err := pem.Encode(&certBuf, &pem.Block{Type: "CERTIFICATE", Bytes: block}); err != nil {
+			return nil, nil, fmt.Errorf("failed to PEM-encode certificate: %w", err)
+		}
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal certificate key: %w", err)
+	}
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return certBuf.Bytes(), keyPEM, nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestObtainACMECertificateRejectsNonDNS01Challenges(t *testing.T) {
+	cfg := &Config{FQDN: "example.com", ACMEChallenge: "http-01"}
+
+	if _, _, err := obtainACMECertificate(context.Background(), cfg); err == nil {
+		t.Fatal("expected an error for a non-dns-01 challenge type")
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Cache lets nitriding persist ACME-issued keys/certs and AppKeys across
+// enclave restarts, instead of renegotiating them from scratch on every cold
+// start. This mirrors golang.org/x/crypto/acme/autocert's Cache interface.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+var errCacheMiss = errors.New("cache: key not found")
+
+// memoryCache is an in-memory Cache. It's the default and matches
+// nitriding's previous behaviour of not persisting keys across restarts.
+type memoryCache struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{data: make(map[string][]byte)}
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.data[key]
+	if !ok {
+		return nil, errCacheMiss
+	}
+	return v, nil
+}
+
+func (c *memoryCache) Put(_ context.Context, key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = data
+	return nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+	return nil
+}
+
+// kmsUnsealer derives and unwraps a per-process key-encryption key (KEK)
+// using AWS KMS. The Decrypt call carries our NSM attestation document as
+// the "recipient", so KMS only releases the plaintext KEK into the enclave:
+// a host-side attacker who steals the ciphertext cannot decrypt it outside
+// an enclave whose PCRs match the KMS key policy.
+type kmsUnsealer struct {
+	client *kms.Client
+	keyID  string
+}
+
+func newKMSUnsealer(ctx context.Context, keyID string) (*kmsUnsealer, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config for KMS: %w", err)
+	}
+	return &kmsUnsealer{client: kms.NewFromConfig(cfg), keyID: keyID}, nil
+}
+
+// unseal asks KMS to decrypt ciphertext, attaching our NSM attestation
+// document so that KMS seals the plaintext response to our enclave's
+// ephemeral public key.
+func (u *kmsUnsealer) unseal(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	doc, err := attest(nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attestation document for KMS: %w", err)
+	}
+
+	out, err := u.client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob: ciphertext,
+		KeyId:          aws.String(u.keyID),
+		Recipient: &types.RecipientInfo{
+			AttestationDocument:    doc,
+			KeyEncryptionAlgorithm: types.KeyEncryptionMechanismRsaesOaepSha256,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMS decrypt failed: %w", err)
+	}
+	return out.Plaintext, nil
+}
+
+// sealedAEAD wraps an unwrapped KEK and seals/opens cache entries with it
+// using AES-256-GCM.
+type sealedAEAD struct {
+	aead cipher.AEAD
+}
+
+func newSealedAEAD(kek []byte) (*sealedAEAD, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher from KEK: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES-GCM AEAD: %w", err)
+	}
+	return &sealedAEAD{aead: aead}, nil
+}
+
+func (s *sealedAEAD) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (s *sealedAEAD) open(ciphertext []byte) ([]byte, error) {
+	nonceLen := s.aead.NonceSize()
+	if len(ciphertext) < nonceLen {
+		return nil, errors.New("ciphertext shorter than nonce")
+	}
+	nonce, sealed := ciphertext[:nonceLen], ciphertext[nonceLen:]
+	return s.aead.Open(nil, nonce, sealed, nil)
+}
+
+// encryptedFileCache is a Cache that persists entries as AES-256-GCM-sealed
+// files on disk. Its KEK is unwrapped once, at startup, via an
+// attestation-gated KMS Decrypt call, so the ciphertext KEK file on the host
+// is useless without a matching enclave.
+type encryptedFileCache struct {
+	dir  string
+	aead *sealedAEAD
+}
+
+// newEncryptedFileCache returns an encryptedFileCache that stores entries
+// under dir, using a KEK unwrapped from wrappedKEK via KMS key keyID.
+func newEncryptedFileCache(ctx context.Context, dir, keyID string, wrappedKEK []byte) (*encryptedFileCache, error) {
+	unsealer, err := newKMSUnsealer(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := unsealer.unseal(ctx, wrappedKEK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal KEK: %w", err)
+	}
+	aead, err := newSealedAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &encryptedFileCache{dir: dir, aead: aead}, nil
+}
+
+func (c *encryptedFileCache) path(key string) string {
+	return filepath.Join(c.dir, url.QueryEscape(key))
+}
+
+func (c *encryptedFileCache) Get(_ context.Context, key string) ([]byte, error) {
+	sealed, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errCacheMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	return c.aead.open(sealed)
+}
+
+func (c *encryptedFileCache) Put(_ context.Context, key string, data []byte) error {
+	sealed, err := c.aead.seal(data)
+	if err != nil {
+		return fmt.Errorf("failed to seal cache entry: %w", err)
+	}
+	return os.WriteFile(c.path(key), sealed, 0o600)
+}
+
+func (c *encryptedFileCache) Delete(_ context.Context, key string) error {
+	err := os.Remove(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// s3Cache is a Cache that persists entries as AES-256-GCM-sealed objects in
+// an S3 bucket, using the same KMS-wrap scheme as encryptedFileCache. This
+// lets operators redeploy enclaves -- even onto different hosts -- without
+// needing a live leader enclave to sync state from.
+type s3Cache struct {
+	client *s3.Client
+	bucket string
+	prefix string
+	aead   *sealedAEAD
+}
+
+// newS3Cache returns an s3Cache that stores entries in bucket under prefix,
+// using a KEK unwrapped from wrappedKEK via KMS key keyID.
+func newS3Cache(ctx context.Context, bucket, prefix, keyID string, wrappedKEK []byte) (*s3Cache, error) {
+	unsealer, err := newKMSUnsealer(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := unsealer.unseal(ctx, wrappedKEK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal KEK: %w", err)
+	}
+	aead, err := newSealedAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config for S3: %w", err)
+	}
+	return &s3Cache{client: s3.NewFromConfig(cfg), bucket: bucket, prefix: prefix, aead: aead}, nil
+}
+
+func (c *s3Cache) objectKey(key string) string {
+	return filepath.Join(c.prefix, key)
+}
+
+func (c *s3Cache) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.objectKey(key)),
+	})
+	if err != nil {
+		// The AWS SDK doesn't expose a typed "not found" error for all
+		// backends consistently, so we fall back to a string check.
+		if isS3NotFound(err) {
+			return nil, errCacheMiss
+		}
+		return nil, fmt.Errorf("failed to fetch cache entry from S3: %w", err)
+	}
+	defer out.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(out.Body); err != nil {
+		return nil, fmt.Errorf("failed to read cache entry from S3: %w", err)
+	}
+	return c.aead.open(buf.Bytes())
+}
+
+func (c *s3Cache) Put(ctx context.Context, key string, data []byte) error {
+	sealed, err := c.aead.seal(data)
+	if err != nil {
+		return fmt.Errorf("failed to seal cache entry: %w", err)
+	}
+	_, err = c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.objectKey(key)),
+		Body:   bytes.NewReader(sealed),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store cache entry in S3: %w", err)
+	}
+	return nil
+}
+
+func (c *s3Cache) Delete(ctx context.Context, key string) error {
+	_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(c.objectKey(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete cache entry from S3: %w", err)
+	}
+	return nil
+}
+
+func isS3NotFound(err error) bool {
+	return err != nil && (errors.Is(err, os.ErrNotExist) ||
+		bytes.Contains([]byte(err.Error()), []byte("NoSuchKey")) ||
+		bytes.Contains([]byte(err.Error()), []byte("NotFound")))
+}
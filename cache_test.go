@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c := newMemoryCache()
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, "missing"); err != errCacheMiss {
+		t.Fatalf("expected errCacheMiss, got %v", err)
+	}
+
+	if err := c.Put(ctx, "key", []byte("value")); err != nil {
+		t.Fatalf("failed to put cache entry: %s", err)
+	}
+	got, err := c.Get(ctx, "key")
+	if err != nil {
+		t.Fatalf("failed to get cache entry: %s", err)
+	}
+	if string(got) != "value" {
+		t.Fatalf("got %q, want %q", got, "value")
+	}
+
+	if err := c.Delete(ctx, "key"); err != nil {
+		t.Fatalf("failed to delete cache entry: %s", err)
+	}
+	if _, err := c.Get(ctx, "key"); err != errCacheMiss {
+		t.Fatalf("expected errCacheMiss after delete, got %v", err)
+	}
+}
+
+func TestSealedAEADRoundTrip(t *testing.T) {
+	kek := make([]byte, 32)
+	aead, err := newSealedAEAD(kek)
+	if err != nil {
+		t.Fatalf("failed to create sealedAEAD: %s", err)
+	}
+
+	plaintext := []byte("super secret key material")
+	ciphertext, err := aead.seal(plaintext)
+	if err != nil {
+		t.Fatalf("failed to seal: %s", err)
+	}
+
+	opened, err := aead.open(ciphertext)
+	if err != nil {
+		t.Fatalf("failed to open: %s", err)
+	}
+	if string(opened) != string(plaintext) {
+		t.Fatalf("got %q, want %q", opened, plaintext)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+)
+
+// Config holds nitriding's configuration. Fields are typically set from
+// command line flags or environment variables by nitriding's caller.
+type Config struct {
+	// FQDN is the fully-qualified domain name that's used for the HTTPS
+	// certificate, be it self-signed or issued via ACME.
+	FQDN string
+	// ExtPort is the TCP port that faces the Internet.
+	ExtPort int
+	// IntPort is the TCP port that faces the enclave-internal application.
+	IntPort int
+	// HostProxyPort is the VSOCK port of the proxy application that's
+	// running on the enclave's host.
+	HostProxyPort int
+	// UseACME instructs nitriding to get an HTTPS certificate via ACME
+	// instead of creating a self-signed certificate.
+	UseACME bool
+	// Debug enables debug-level logging and profiling endpoints.
+	Debug bool
+	// FdCur and FdMax set the enclave's soft and hard file descriptor
+	// resource limits, respectively.
+	FdCur uint64
+	FdMax uint64
+	// WaitForApp instructs nitriding to not start its Internet-facing Web
+	// server until the enclave application signalled its readiness.
+	WaitForApp bool
+	// AppURL, if set, points to the enclave application's source code and
+	// is shown on nitriding's index page.
+	AppURL *url.URL
+
+	// ACMEChallenge selects the ACME challenge type: "http-01" (the
+	// default), "tls-alpn-01", or "dns-01". Only "dns-01" supports
+	// certificates for wildcard domains.
+	ACMEChallenge string
+	// ACMEDNSProvider names the DNSProvider to use when ACMEChallenge is
+	// "dns-01": "route53", "cloudflare", or "rfc2136".
+	ACMEDNSProvider string
+	// ACMEDNSProviderCreds holds the selected DNS provider's credentials
+	// (e.g. an API token, or a hosted zone ID). This field carries no
+	// guarantee of its own about how it was populated: callers should only
+	// set it from values obtained over nitriding's attestation-gated
+	// internal API, so that secrets are never handed to the enclave via
+	// the untrusted host.
+	ACMEDNSProviderCreds map[string]string
+
+	// ACMEDirectoryURL is the ACME server's directory endpoint. It defaults
+	// to Let's Encrypt's directory; operators set it to point nitriding at
+	// an internal CA (e.g. smallstep/step-ca, Vault PKI, EJBCA) instead.
+	ACMEDirectoryURL string
+	// ACMEEABKeyID and ACMEEABHMACKey are the External Account Binding
+	// credentials defined in RFC 8555 ยง7.3.4, required by most internal
+	// CAs. As with ACMEDNSProviderCreds, callers should only populate these
+	// from values obtained over nitriding's attestation-gated internal
+	// API, so the plaintext HMAC key is never written to the host's disk --
+	// this package itself doesn't enforce that.
+	ACMEEABKeyID   string
+	ACMEEABHMACKey []byte
+}
+
+var (
+	errNoFQDN          = errors.New("no FQDN given")
+	errNoExtPort       = errors.New("no external port given")
+	errNoIntPort       = errors.New("no internal port given")
+	errNoHostProxyPort = errors.New("no host proxy port given")
+)
+
+// Validate returns an error if required configuration fields are unset.
+func (c *Config) Validate() error {
+	if c.FQDN == "" {
+		return errNoFQDN
+	}
+	if c.ExtPort == 0 {
+		return errNoExtPort
+	}
+	if c.IntPort == 0 {
+		return errNoIntPort
+	}
+	if c.HostProxyPort == 0 {
+		return errNoHostProxyPort
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/miekg/dns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// DNSProvider lets our ACME client complete DNS-01 challenges by publishing
+// and removing the TXT record that proves control over a domain. This lets
+// enclaves obtain wildcard certificates, which the HTTP-01 and TLS-ALPN-01
+// challenge types can't do.
+//
+// The interface mirrors the provider plugins popularized by Traefik/lego.
+type DNSProvider interface {
+	// Present publishes the TXT record that satisfies the ACME challenge
+	// for domain.
+	Present(ctx context.Context, domain, token, keyAuth string) error
+	// CleanUp removes the TXT record that Present published.
+	CleanUp(ctx context.Context, domain, token, keyAuth string) error
+}
+
+// dns01TXTName returns the name of the TXT record that proves control over
+// domain, as defined by RFC 8555 ยง8.4. For a wildcard identifier
+// (e.g. "*.enclave.example.com"), the record belongs to the base domain,
+// not to a "*." subdomain that doesn't itself exist in DNS.
+func dns01TXTName(domain string) string {
+	base := strings.TrimPrefix(domain, "*.")
+	return "_acme-challenge." + strings.TrimSuffix(base, ".") + "."
+}
+
+// route53Provider implements DNSProvider using AWS Route53.
+type route53Provider struct {
+	client       *route53.Client
+	hostedZoneID string
+}
+
+// newRoute53Provider returns a DNSProvider that publishes TXT records in the
+// given Route53 hosted zone, using credentials from the enclave's
+// environment (sourced from the internal API after attestation).
+func newRoute53Provider(ctx context.Context, hostedZoneID string) (*route53Provider, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config for Route53: %w", err)
+	}
+	return &route53Provider{client: route53.NewFromConfig(cfg), hostedZoneID: hostedZoneID}, nil
+}
+
+func (p *route53Provider) Present(ctx context.Context, domain, _, keyAuth string) error {
+	return p.changeRecord(ctx, domain, keyAuth, types.ChangeActionUpsert)
+}
+
+func (p *route53Provider) CleanUp(ctx context.Context, domain, _, keyAuth string) error {
+	return p.changeRecord(ctx, domain, keyAuth, types.ChangeActionDelete)
+}
+
+func (p *route53Provider) changeRecord(ctx context.Context, domain, keyAuth string, action types.ChangeAction) error {
+	name := dns01TXTName(domain)
+	_, err := p.client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(p.hostedZoneID),
+		ChangeBatch: &types.ChangeBatch{
+			Changes: []types.Change{{
+				Action: action,
+				ResourceRecordSet: &types.ResourceRecordSet{
+					Name:            aws.String(name),
+					Type:            types.RRTypeTxt,
+					TTL:             aws.Int64(30),
+					ResourceRecords: []types.ResourceRecord{{Value: aws.String(`"` + keyAuth + `"`)}},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update Route53 TXT record: %w", err)
+	}
+	return nil
+}
+
+// cloudflareProvider implements DNSProvider using the Cloudflare DNS API.
+type cloudflareProvider struct {
+	api    *cloudflare.API
+	zoneID string
+}
+
+// newCloudflareProvider returns a DNSProvider that publishes TXT records in
+// the given Cloudflare zone, authenticating with apiToken.
+func newCloudflareProvider(apiToken, zoneID string) (*cloudflareProvider, error) {
+	api, err := cloudflare.NewWithAPIToken(apiToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloudflare client: %w", err)
+	}
+	return &cloudflareProvider{api: api, zoneID: zoneID}, nil
+}
+
+func (p *cloudflareProvider) Present(ctx context.Context, domain, _, keyAuth string) error {
+	rc := cloudflare.ZoneIdentifier(p.zoneID)
+	_, err := p.api.CreateDNSRecord(ctx, rc, cloudflare.CreateDNSRecordParams{
+		Type:    "TXT",
+		Name:    dns01TXTName(domain),
+		Content: keyAuth,
+		TTL:     60,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create Cloudflare TXT record: %w", err)
+	}
+	return nil
+}
+
+func (p *cloudflareProvider) CleanUp(ctx context.Context, domain, _, keyAuth string) error {
+	rc := cloudflare.ZoneIdentifier(p.zoneID)
+	records, _, err := p.api.ListDNSRecords(ctx, rc, cloudflare.ListDNSRecordsParams{
+		Type: "TXT",
+		Name: dns01TXTName(domain),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list Cloudflare TXT records: %w", err)
+	}
+	for _, rec := range records {
+		if rec.Content != keyAuth {
+			continue
+		}
+		if err := p.api.DeleteDNSRecord(ctx, rc, rec.ID); err != nil {
+			return fmt.Errorf("failed to delete Cloudflare TXT record: %w", err)
+		}
+	}
+	return nil
+}
+
+// rfc2136Provider implements DNSProvider using RFC 2136 dynamic DNS updates,
+// for operators who run their own authoritative nameserver (e.g. BIND).
+type rfc2136Provider struct {
+	nameserver string
+	tsigKey    string
+	tsigSecret string
+	tsigAlgo   string
+}
+
+// newRFC2136Provider returns a DNSProvider that sends dynamic updates to
+// nameserver (host:port), authenticated with the given TSIG key.
+func newRFC2136Provider(nameserver, tsigKey, tsigSecret, tsigAlgo string) *rfc2136Provider {
+	if tsigAlgo == "" {
+		tsigAlgo = dns.HmacSHA256
+	}
+	return &rfc2136Provider{
+		nameserver: nameserver,
+		tsigKey:    tsigKey,
+		tsigSecret: tsigSecret,
+		tsigAlgo:   tsigAlgo,
+	}
+}
+
+func (p *rfc2136Provider) Present(_ context.Context, domain, _, keyAuth string) error {
+	return p.update(domain, keyAuth, false)
+}
+
+func (p *rfc2136Provider) CleanUp(_ context.Context, domain, _, keyAuth string) error {
+	return p.update(domain, keyAuth, true)
+}
+
+func (p *rfc2136Provider) update(domain, keyAuth string, remove bool) error {
+	name := dns01TXTName(domain)
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(domain))
+
+	rr, err := dns.NewRR(fmt.Sprintf("%s 30 IN TXT %q", name, keyAuth))
+	if err != nil {
+		return fmt.Errorf("failed to build TXT record: %w", err)
+	}
+	if remove {
+		m.Remove([]dns.RR{rr})
+	} else {
+		m.Insert([]dns.RR{rr})
+	}
+
+	if p.tsigKey != "" {
+		m.SetTsig(dns.Fqdn(p.tsigKey), p.tsigAlgo, 300, 0)
+	}
+
+	c := new(dns.Client)
+	c.TsigSecret = map[string]string{dns.Fqdn(p.tsigKey): p.tsigSecret}
+	if _, _, err := c.Exchange(m, p.nameserver); err != nil {
+		return fmt.Errorf("RFC 2136 dynamic update failed: %w", err)
+	}
+	return nil
+}
+
+// newDNSProvider builds the DNSProvider named by providerName, reading its
+// credentials from creds (see Config.ACMEDNSProviderCreds). Nothing in this
+// package enforces how creds was populated: callers are expected to only
+// set it from values obtained over nitriding's attestation-gated internal
+// API, so that host-side operators never see the plaintext credentials --
+// but that's a property of the caller, not a guarantee this function makes.
+func newDNSProvider(ctx context.Context, providerName string, creds map[string]string) (DNSProvider, error) {
+	switch providerName {
+	case "route53":
+		return newRoute53Provider(ctx, creds["hosted_zone_id"])
+	case "cloudflare":
+		return newCloudflareProvider(creds["api_token"], creds["zone_id"])
+	case "rfc2136":
+		return newRFC2136Provider(creds["nameserver"], creds["tsig_key"], creds["tsig_secret"], creds["tsig_algo"]), nil
+	default:
+		return nil, fmt.Errorf("unknown DNS-01 provider %q", providerName)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDNS01TXTName(t *testing.T) {
+	got := dns01TXTName("example.com")
+	want := "_acme-challenge.example.com."
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestDNS01TXTNameStripsWildcardPrefix(t *testing.T) {
+	got := dns01TXTName("*.enclave.example.com")
+	want := "_acme-challenge.enclave.example.com."
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestNewDNSProviderRejectsUnknownProvider(t *testing.T) {
+	if _, err := newDNSProvider(context.Background(), "does-not-exist", nil); err == nil {
+		t.Fatal("expected an error for an unknown DNS-01 provider")
+	}
+}
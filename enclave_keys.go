@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"sync"
 )
 
@@ -11,11 +14,69 @@ import (
 // if horizontal scaling is required -- synced to worker enclaves.  The struct
 // implements getters and setters that allow for thread-safe setting and getting
 // of members.
+//
+// If cache is set, the keys are also persisted through it under cacheKey, so
+// a leader enclave that's ACME-rate-limited doesn't have to re-issue a
+// certificate -- or re-generate AppKeys -- every time it restarts.
 type enclaveKeys struct {
 	sync.RWMutex
 	NitridingKey  []byte `json:"nitriding_key"`
 	NitridingCert []byte `json:"nitriding_cert"`
 	AppKeys       []byte `json:"app_keys"`
+
+	cache    Cache
+	cacheKey string
+}
+
+// newEnclaveKeys returns an enclaveKeys that persists its contents through
+// cache under cacheKey. cache may be nil, in which case keys are kept
+// in-memory only, matching nitriding's previous behaviour.
+func newEnclaveKeys(cache Cache, cacheKey string) *enclaveKeys {
+	return &enclaveKeys{cache: cache, cacheKey: cacheKey}
+}
+
+// loadFromCache restores key material that was persisted by an earlier
+// instance of the enclave, if any is found under the configured cache key.
+// A cache miss is not an error: it just means there's nothing to restore
+// yet, e.g. on the very first boot.
+func (e *enclaveKeys) loadFromCache(ctx context.Context) error {
+	if e.cache == nil {
+		return nil
+	}
+
+	raw, err := e.cache.Get(ctx, e.cacheKey)
+	if err == errCacheMiss {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load enclave keys from cache: %w", err)
+	}
+
+	var loaded enclaveKeys
+	if err := json.Unmarshal(raw, &loaded); err != nil {
+		return fmt.Errorf("failed to unmarshal cached enclave keys: %w", err)
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	e.NitridingKey = loaded.NitridingKey
+	e.NitridingCert = loaded.NitridingCert
+	e.AppKeys = loaded.AppKeys
+	return nil
+}
+
+// persist writes the current key material through the cache, if one is
+// configured. It must be called with e's lock already held.
+func (e *enclaveKeys) persist() error {
+	if e.cache == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal enclave keys: %w", err)
+	}
+	return e.cache.Put(context.Background(), e.cacheKey, raw)
 }
 
 func (e1 *enclaveKeys) equal(e2 *enclaveKeys) bool {
@@ -34,6 +95,9 @@ func (e *enclaveKeys) setAppKeys(appKeys []byte) {
 	defer e.Unlock()
 
 	e.AppKeys = appKeys
+	if err := e.persist(); err != nil {
+		elog.Printf("Failed to persist app keys to cache: %s", err)
+	}
 }
 
 func (e *enclaveKeys) setNitridingKeys(key, cert []byte) {
@@ -42,6 +106,9 @@ func (e *enclaveKeys) setNitridingKeys(key, cert []byte) {
 
 	e.NitridingKey = key
 	e.NitridingCert = cert
+	if err := e.persist(); err != nil {
+		elog.Printf("Failed to persist nitriding keys to cache: %s", err)
+	}
 }
 
 func (e *enclaveKeys) set(newKeys *enclaveKeys) {
@@ -51,6 +118,9 @@ func (e *enclaveKeys) set(newKeys *enclaveKeys) {
 	e.NitridingKey = newKeys.NitridingKey
 	e.NitridingCert = newKeys.NitridingCert
 	e.AppKeys = newKeys.AppKeys
+	if err := e.persist(); err != nil {
+		elog.Printf("Failed to persist synced keys to cache: %s", err)
+	}
 }
 
 func (e *enclaveKeys) get() *enclaveKeys {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestEnclaveKeysPersistAndReloadThroughCache(t *testing.T) {
+	cache := newMemoryCache()
+	keys := newEnclaveKeys(cache, "enclave-keys")
+
+	keys.setNitridingKeys([]byte("nitriding-key"), []byte("nitriding-cert"))
+	keys.setAppKeys([]byte("app-keys"))
+
+	restored := newEnclaveKeys(cache, "enclave-keys")
+	if err := restored.loadFromCache(context.Background()); err != nil {
+		t.Fatalf("failed to load enclave keys from cache: %s", err)
+	}
+
+	if !bytes.Equal(restored.NitridingKey, []byte("nitriding-key")) {
+		t.Fatalf("got nitriding key %q, want %q", restored.NitridingKey, "nitriding-key")
+	}
+	if !bytes.Equal(restored.NitridingCert, []byte("nitriding-cert")) {
+		t.Fatalf("got nitriding cert %q, want %q", restored.NitridingCert, "nitriding-cert")
+	}
+	if !bytes.Equal(restored.AppKeys, []byte("app-keys")) {
+		t.Fatalf("got app keys %q, want %q", restored.AppKeys, "app-keys")
+	}
+}
+
+func TestEnclaveKeysLoadFromCacheIsANoOpOnMiss(t *testing.T) {
+	keys := newEnclaveKeys(newMemoryCache(), "enclave-keys")
+	if err := keys.loadFromCache(context.Background()); err != nil {
+		t.Fatalf("expected a cache miss to be silently ignored, got: %s", err)
+	}
+	if keys.AppKeys != nil {
+		t.Fatal("expected no app keys to be set after a cache miss")
+	}
+}
+
+func TestEnclaveKeysWithoutCacheDoesNotPanic(t *testing.T) {
+	keys := newEnclaveKeys(nil, "")
+	keys.setAppKeys([]byte("app-keys"))
+	if err := keys.loadFromCache(context.Background()); err != nil {
+		t.Fatalf("expected loadFromCache to be a no-op without a cache, got: %s", err)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -26,6 +28,10 @@ var (
 	errNoAddr         = errors.New("parameter 'addr' not found")
 	errBadSyncAddr    = errors.New("invalid 'addr' parameter for sync")
 	errHashWrongSize  = errors.New("given hash is of invalid size")
+	errFailedSTH      = errors.New("failed to compute signed tree head")
+	errNoHash         = errors.New("parameter 'hash' not found")
+	errBadHash        = errors.New("parameter 'hash' is not valid hex")
+	errBadTreeSize    = errors.New("invalid or missing 'from'/'to' tree size")
 )
 
 func formatIndexPage(appURL *url.URL) string {
@@ -132,7 +138,7 @@ func putStateHandler(e *Enclave) http.HandlerFunc {
 //
 // This is an enclave-internal endpoint that can only be accessed by the
 // trusted enclave application.
-func hashHandler(e *Enclave) http.HandlerFunc {
+func hashHandler(e *Enclave, log *merkleTransparencyLog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Allow an extra byte for the \n.
 		maxReadLen := base64.StdEncoding.EncodedLen(sha256.Size) + 1
@@ -156,6 +162,18 @@ func hashHandler(e *Enclave) http.HandlerFunc {
 			return
 		}
 		copy(e.hashes.appKeyHash[:], keyHash)
+
+		// Record the newly-deployed app's hash in the transparency log, so
+		// that it's publicly logged before any client trusts the
+		// attestation document that attestationHandler will later embed
+		// our signed tree head in. Without this call, the log stays
+		// permanently empty and the split-view protection it exists to
+		// provide is vacuous.
+		if log != nil {
+			if err := log.Append(keyHash); err != nil {
+				elog.Printf("Failed to append app hash to transparency log: %s", err)
+			}
+		}
 	}
 }
 
@@ -185,14 +203,18 @@ func configHandler(cfg *Config) http.HandlerFunc {
 	}
 }
 
-// attestationHandler takes as input a flag indicating if profiling is enabled
-// and an AttestationHashes struct, and returns a HandlerFunc.  If profiling is
-// enabled, we abort attestation because profiling leaks enclave-internal data.
-// The returned HandlerFunc expects a nonce in the URL query parameters and
-// subsequently asks its hypervisor for an attestation document that contains
-// both the nonce and the hashes in the given struct.  The resulting
-// Base64-encoded attestation document is then returned to the requester.
-func attestationHandler(useProfiling bool, hashes *AttestationHashes) http.HandlerFunc {
+// attestationHandler takes as input a flag indicating if profiling is enabled,
+// an AttestationHashes struct, and (optionally) the enclave's transparency
+// log, and returns a HandlerFunc.  If profiling is enabled, we abort
+// attestation because profiling leaks enclave-internal data.  The returned
+// HandlerFunc expects a nonce in the URL query parameters and subsequently
+// asks its hypervisor for an attestation document that contains the nonce,
+// the hashes in the given struct, and -- if a transparency log was given --
+// its current signed tree head, so clients can verify not only which image
+// the enclave is running but also that the image was publicly logged before
+// they trusted it.  The resulting Base64-encoded attestation document is
+// then returned to the requester.
+func attestationHandler(useProfiling bool, hashes *AttestationHashes, log *merkleTransparencyLog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if useProfiling {
 			http.Error(w, errProfilingSet, http.StatusServiceUnavailable)
@@ -216,7 +238,17 @@ func attestationHandler(useProfiling bool, hashes *AttestationHashes) http.Handl
 			return
 		}
 
-		rawDoc, err := attest(rawNonce, hashes.Serialize(), nil)
+		userData := hashes.Serialize()
+		if log != nil {
+			sth, err := log.SignedTreeHead()
+			if err != nil {
+				http.Error(w, errFailedAttestation, http.StatusInternalServerError)
+				return
+			}
+			userData = append(userData, sth.Serialize()...)
+		}
+
+		rawDoc, err := attest(rawNonce, userData, nil)
 		if err != nil {
 			http.Error(w, errFailedAttestation, http.StatusInternalServerError)
 			return
@@ -227,9 +259,90 @@ func attestationHandler(useProfiling bool, hashes *AttestationHashes) http.Handl
 }
 
 // transparencyLogHandler prints the transparency log of all previously-deployed
-// enclave applications in human-readable form.
-func transparencyLogHandler(log transparencyLog) http.HandlerFunc {
+// enclave applications in human-readable form. It used to print a flat list
+// of app hashes; now that the log is a Merkle tree (merkleTransparencyLog),
+// it prints the current signed tree head instead, since that's what
+// summarizes "all previously-deployed enclave applications" in a form that
+// clients can also verify proofs against via /log/sth, /log/proof, and
+// /log/consistency.
+func transparencyLogHandler(log *merkleTransparencyLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sth, err := log.SignedTreeHead()
+		if err != nil {
+			http.Error(w, errFailedSTH.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "Transparency log: %d entries, root hash %x, as of %s\n",
+			sth.TreeSize, sth.RootHash, sth.Timestamp)
+	}
+}
+
+// sthHandler returns a handler for GET /log/sth that reports the
+// transparency log's current signed tree head.
+func sthHandler(log *merkleTransparencyLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sth, err := log.SignedTreeHead()
+		if err != nil {
+			http.Error(w, errFailedSTH.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sth)
+	}
+}
+
+// proofHandler returns a handler for GET /log/proof?hash=... that reports an
+// inclusion proof (leaf index plus audit path) for the logged entry -- an
+// app's key hash, as appended by hashHandler -- whose hex encoding is given.
+// The parameter is the entry itself, not its RFC 6962 leaf hash.
+func proofHandler(log *merkleTransparencyLog) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, log)
+		hash := r.URL.Query().Get("hash")
+		if hash == "" {
+			http.Error(w, errNoHash.Error(), http.StatusBadRequest)
+			return
+		}
+		entry, err := hex.DecodeString(hash)
+		if err != nil {
+			http.Error(w, errBadHash.Error(), http.StatusBadRequest)
+			return
+		}
+
+		idx, path, err := log.InclusionProof(entry)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			LeafIndex int64    `json:"leaf_index"`
+			AuditPath [][]byte `json:"audit_path"`
+		}{idx, path})
+	}
+}
+
+// consistencyHandler returns a handler for GET /log/consistency?from=&to=
+// that reports a consistency proof between two previously-observed tree
+// sizes.
+func consistencyHandler(log *merkleTransparencyLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, err1 := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		to, err2 := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+		if err1 != nil || err2 != nil {
+			http.Error(w, errBadTreeSize.Error(), http.StatusBadRequest)
+			return
+		}
+
+		proof, err := log.ConsistencyProof(from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Proof [][]byte `json:"proof"`
+		}{proof})
 	}
 }
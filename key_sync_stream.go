@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sessionPubkeyHeader carries the leader's ephemeral X25519 public key back
+// to the worker in the sync stream's response, so both sides end up with
+// the other's ECDH public key and can derive the same session AEAD. Without
+// this, only the leader ever learns the worker's public key (embedded in
+// its attestation document); the worker has no channel back for the
+// leader's half of the exchange.
+const sessionPubkeyHeader = "X-Session-Pubkey"
+
+// newSyncLagGauge registers and returns the gauge that exposes how many
+// seconds have passed since a worker last received a key update over the
+// push sync stream, so operators can alarm on stragglers. The gauge lives on
+// the worker; its "worker" label carries the address of the leader whose
+// stream that worker reads.
+func newSyncLagGauge(reg prometheus.Registerer) *prometheus.GaugeVec {
+	g := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "nitriding",
+			Name:      "sync_lag_seconds",
+			Help:      "Seconds since this worker last received a key update over the sync stream",
+		},
+		[]string{"worker"},
+	)
+	reg.MustRegister(g)
+	return g
+}
+
+// lagTracker continuously reports how long it's been since a worker's sync
+// stream last produced a usable key update. Unlike a one-shot Set(0) on
+// receipt, it keeps updating the gauge while the connection is merely idle
+// or stalled, which is the straggler case the metric exists to catch.
+type lagTracker struct {
+	gauge prometheus.Gauge
+	mu    sync.Mutex
+	last  time.Time
+}
+
+func newLagTracker(gauge *prometheus.GaugeVec, worker string) *lagTracker {
+	return &lagTracker{gauge: gauge.WithLabelValues(worker), last: currentTime()}
+}
+
+// markFresh records that a key update was just received.
+func (t *lagTracker) markFresh() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last = currentTime()
+}
+
+// run ticks the gauge to time.Since(lastUpdate) every second until ctx is
+// canceled, so the metric keeps climbing even while the connection is
+// stalled and no frame is arriving to reset it.
+func (t *lagTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.mu.Lock()
+			lag := currentTime().Sub(t.last)
+			t.mu.Unlock()
+			t.gauge.Set(lag.Seconds())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// syncSubscriber is a single worker's push-sync connection. Frames written
+// to out are AEAD-sealed enclaveKeys payloads.
+type syncSubscriber struct {
+	out  chan []byte
+	aead cipher.AEAD
+}
+
+// syncBroadcaster fans out enclaveKeys updates to every connected worker's
+// SSE stream, replacing the poll-based reqSyncHandler/RequestKeys round
+// trip with near-instant push delivery.
+type syncBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[*syncSubscriber]bool
+}
+
+func newSyncBroadcaster() *syncBroadcaster {
+	return &syncBroadcaster{subscribers: make(map[*syncSubscriber]bool)}
+}
+
+func (b *syncBroadcaster) subscribe(aead cipher.AEAD) *syncSubscriber {
+	sub := &syncSubscriber{out: make(chan []byte, 8), aead: aead}
+	b.mu.Lock()
+	b.subscribers[sub] = true
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *syncBroadcaster) unsubscribe(sub *syncSubscriber) {
+	b.mu.Lock()
+	delete(b.subscribers, sub)
+	b.mu.Unlock()
+	close(sub.out)
+}
+
+// subscriberCount returns the number of workers currently connected to the
+// sync stream.
+func (b *syncBroadcaster) subscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// notify seals keys for every connected subscriber and pushes the result
+// onto its stream. It's meant to be called right after setAppKeys or
+// setNitridingKeys mutates the leader's enclaveKeys.
+func (b *syncBroadcaster) notify(keys *enclaveKeys) {
+	raw, err := json.Marshal(keys.get())
+	if err != nil {
+		elog.Printf("Sync stream: failed to marshal enclave keys: %s", err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		sealed, err := sealFrame(sub.aead, raw)
+		if err != nil {
+			elog.Printf("Sync stream: failed to seal frame: %s", err)
+			continue
+		}
+		select {
+		case sub.out <- sealed:
+		default:
+			elog.Println("Sync stream: subscriber's buffer is full; dropping frame")
+		}
+	}
+}
+
+func sealFrame(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openFrame(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	nonceLen := aead.NonceSize()
+	if len(sealed) < nonceLen {
+		return nil, errors.New("sealed frame shorter than nonce")
+	}
+	nonce, ciphertext := sealed[:nonceLen], sealed[nonceLen:]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+// deriveSessionAEAD derives a symmetric AES-256-GCM AEAD from an X25519
+// shared secret, reusing the existing workerAuxInfo/leaderAuxInfo attestation
+// handshake to authenticate the public keys that feed into it. A compromised
+// host that merely proxies HTTP traffic cannot substitute its own key
+// updates because it never learns the shared secret.
+func deriveSessionAEAD(ourPriv *ecdh.PrivateKey, theirPub *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := ourPriv.ECDH(theirPub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute ECDH shared secret: %w", err)
+	}
+	sessionKey := sha256.Sum256(shared)
+
+	block, err := aes.NewCipher(sessionKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// syncStreamHandler returns a handler for the leader's GET
+// /enclave/sync/stream endpoint. A worker opens this endpoint once per
+// connection, completes the existing attestation handshake to derive a
+// session key, and then receives AEAD-sealed enclaveKeys updates as
+// Server-Sent Events whenever setAppKeys or setNitridingKeys is called.
+func syncStreamHandler(att attester, nonceCache nonceCache, broadcaster *syncBroadcaster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		aead, ourPub, err := handshakeWithWorker(r, att, nonceCache)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("attestation handshake failed: %s", err), http.StatusUnauthorized)
+			return
+		}
+
+		// Send our half of the ECDH exchange back to the worker before we
+		// commit to the response status and start streaming, since headers
+		// can't be changed afterwards.
+		w.Header().Set(sessionPubkeyHeader, base64.StdEncoding.EncodeToString(ourPub.Bytes()))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		sub := broadcaster.subscribe(aead)
+		defer broadcaster.unsubscribe(sub)
+
+		for {
+			select {
+			case frame, ok := <-sub.out:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(w, "data: %s\n\n", base64.StdEncoding.EncodeToString(frame))
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// nonceCache abstracts the lookup that the attester interface's
+// isOurNonce callback performs, so syncStreamHandler can reuse whatever
+// nonce cache the rest of the leader already maintains.
+type nonceCache interface {
+	isOurNonce(b64Nonce string) bool
+}
+
+// handshakeWithWorker performs the attestation handshake embedded in the
+// sync stream's connection setup and returns the derived session AEAD along
+// with the leader's ephemeral public key, which the caller must hand back
+// to the worker (see sessionPubkeyHeader).
+func handshakeWithWorker(r *http.Request, att attester, nc nonceCache) (cipher.AEAD, *ecdh.PublicKey, error) {
+	b64Doc := r.URL.Query().Get("attestation")
+	if b64Doc == "" {
+		return nil, nil, errors.New("missing 'attestation' query parameter")
+	}
+	doc, err := base64.StdEncoding.DecodeString(b64Doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("attestation document is not valid base64: %w", err)
+	}
+
+	aux, err := att.verifyAttstn(doc, nc.isOurNonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, ok := aux.(*workerAuxInfo)
+	if !ok {
+		return nil, nil, errors.New("expected a worker's auxiliary information")
+	}
+
+	theirPub, err := ecdh.X25519().NewPublicKey(w.PublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid worker public key: %w", err)
+	}
+	ourPriv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	aead, err := deriveSessionAEAD(ourPriv, theirPub)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, ourPriv.PublicKey(), nil
+}
+
+// connectSyncStream dials a leader's sync stream, completing the other half
+// of the attestation handshake: it generates its own ephemeral X25519 key,
+// embeds the public half in an attestation document, and -- once connected
+// -- reads the leader's ephemeral public key back from sessionPubkeyHeader
+// to derive the same session AEAD that the leader derived in
+// handshakeWithWorker.
+func connectSyncStream(ctx context.Context, addr string, att attester, workersNonce, leadersNonce nonce) (*http.Response, cipher.AEAD, error) {
+	ourPriv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	doc, err := att.createAttstn(workerAuxInfo{
+		WorkersNonce: workersNonce,
+		LeadersNonce: leadersNonce,
+		PublicKey:    ourPriv.PublicKey().Bytes(),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create attestation document: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/enclave/sync/stream?attestation=%s",
+		strings.TrimSuffix(addr, "/"), base64.StdEncoding.EncodeToString(doc))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build sync stream request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to sync stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("sync stream returned status %d", resp.StatusCode)
+	}
+
+	b64Pub := resp.Header.Get(sessionPubkeyHeader)
+	if b64Pub == "" {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("leader did not return a %s header", sessionPubkeyHeader)
+	}
+	rawPub, err := base64.StdEncoding.DecodeString(b64Pub)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("leader's session public key is not valid base64: %w", err)
+	}
+	theirPub, err := ecdh.X25519().NewPublicKey(rawPub)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("invalid leader session public key: %w", err)
+	}
+
+	aead, err := deriveSessionAEAD(ourPriv, theirPub)
+	if err != nil {
+		resp.Body.Close()
+		return nil, nil, err
+	}
+	return resp, aead, nil
+}
+
+// streamKeys connects to a leader's sync stream and applies every
+// AEAD-sealed enclaveKeys update it receives to dst, until ctx is canceled.
+// On disconnect, it reconnects with exponential backoff, redoing the
+// attestation handshake (and deriving a fresh session key) on every attempt.
+func streamKeys(ctx context.Context, addr string, att attester, workersNonce, leadersNonce nonce, dst *enclaveKeys, lag *prometheus.GaugeVec) error {
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	tracker := newLagTracker(lag, addr)
+	trackerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go tracker.run(trackerCtx)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := streamKeysOnce(ctx, addr, att, workersNonce, leadersNonce, dst, tracker); err != nil {
+			elog.Printf("Sync stream: connection to %s failed: %s; reconnecting in %s", addr, err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if backoff < maxBackoff {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+func streamKeysOnce(ctx context.Context, addr string, att attester, workersNonce, leadersNonce nonce, dst *enclaveKeys, tracker *lagTracker) error {
+	resp, aead, err := connectSyncStream(ctx, addr, att, workersNonce, leadersNonce)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "data: "))
+		if err != nil {
+			elog.Printf("Sync stream: failed to decode frame: %s", err)
+			continue
+		}
+		plaintext, err := openFrame(aead, sealed)
+		if err != nil {
+			elog.Printf("Sync stream: failed to open frame: %s", err)
+			continue
+		}
+		var newKeys enclaveKeys
+		if err := json.Unmarshal(plaintext, &newKeys); err != nil {
+			elog.Printf("Sync stream: failed to unmarshal enclave keys: %s", err)
+			continue
+		}
+		dst.set(&newKeys)
+		tracker.markFresh()
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSSEFrame reads lines from an SSE body until it finds one that carries
+// a "data: " frame, and returns the decoded payload.
+func readSSEFrame(body io.Reader) ([]byte, error) {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "data: "))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no SSE frame found before the stream ended")
+}
+
+func TestSealOpenFrameRoundTrip(t *testing.T) {
+	priv1, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	priv2, _ := ecdh.X25519().GenerateKey(rand.Reader)
+
+	aead1, err := deriveSessionAEAD(priv1, priv2.PublicKey())
+	if err != nil {
+		t.Fatalf("failed to derive session AEAD: %s", err)
+	}
+	aead2, err := deriveSessionAEAD(priv2, priv1.PublicKey())
+	if err != nil {
+		t.Fatalf("failed to derive session AEAD: %s", err)
+	}
+
+	plaintext := []byte("enclave keys payload")
+	sealed, err := sealFrame(aead1, plaintext)
+	if err != nil {
+		t.Fatalf("failed to seal frame: %s", err)
+	}
+
+	opened, err := openFrame(aead2, sealed)
+	if err != nil {
+		t.Fatalf("failed to open frame: %s", err)
+	}
+	if string(opened) != string(plaintext) {
+		t.Fatalf("got %q, want %q", opened, plaintext)
+	}
+}
+
+func TestSyncBroadcasterNotifiesSubscribers(t *testing.T) {
+	priv, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	peerPriv, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	aead, err := deriveSessionAEAD(priv, peerPriv.PublicKey())
+	if err != nil {
+		t.Fatalf("failed to derive session AEAD: %s", err)
+	}
+
+	b := newSyncBroadcaster()
+	sub := b.subscribe(aead)
+	defer b.unsubscribe(sub)
+
+	keys := &enclaveKeys{AppKeys: []byte("app-keys")}
+	b.notify(keys)
+
+	select {
+	case frame := <-sub.out:
+		if len(frame) == 0 {
+			t.Fatal("expected a non-empty frame")
+		}
+	default:
+		t.Fatal("expected a frame to be queued for the subscriber")
+	}
+}
+
+// fakeAttester is a test-only attester that skips real NSM/nitrite
+// machinery: createAttstn just marshals its input to JSON (like
+// dummyAttester), and verifyAttstn unmarshals it back without checking
+// nonces, since exercising the real nonce cache isn't what these tests are
+// about.
+type fakeAttester struct{}
+
+func (*fakeAttester) createAttstn(aux auxInfo) ([]byte, error) {
+	return json.Marshal(aux)
+}
+
+func (*fakeAttester) verifyAttstn(doc []byte, _ func(string) bool) (auxInfo, error) {
+	var w workerAuxInfo
+	if err := json.Unmarshal(doc, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+type alwaysTrustingNonceCache struct{}
+
+func (alwaysTrustingNonceCache) isOurNonce(string) bool { return true }
+
+// TestSyncStreamEndToEnd drives both the leader's syncStreamHandler and the
+// worker's connectSyncStream over a real HTTP connection, verifying that
+// the leader's ephemeral public key actually makes it back to the worker
+// (via sessionPubkeyHeader) so the two sides derive the same session AEAD.
+func TestSyncStreamEndToEnd(t *testing.T) {
+	att := &fakeAttester{}
+	broadcaster := newSyncBroadcaster()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/enclave/sync/stream", syncStreamHandler(att, alwaysTrustingNonceCache{}, broadcaster))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, clientAEAD, err := connectSyncStream(ctx, server.URL, att, nonce("workers-nonce-012345"), nonce("leaders-nonce-012345"))
+	if err != nil {
+		t.Fatalf("failed to connect to sync stream: %s", err)
+	}
+	defer resp.Body.Close()
+
+	// Wait for the server side to finish subscribing before publishing an
+	// update, since subscription happens after the response headers (which
+	// the client has, by now, already received) are flushed.
+	deadline := time.Now().Add(2 * time.Second)
+	for broadcaster.subscriberCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the server to subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	want := &enclaveKeys{AppKeys: []byte("fresh-app-keys")}
+	broadcaster.notify(want)
+
+	sealed, err := readSSEFrame(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read SSE frame: %s", err)
+	}
+
+	plaintext, err := openFrame(clientAEAD, sealed)
+	if err != nil {
+		t.Fatalf("client failed to open frame sealed by the leader: %s", err)
+	}
+
+	var got enclaveKeys
+	if err := json.Unmarshal(plaintext, &got); err != nil {
+		t.Fatalf("failed to unmarshal enclave keys: %s", err)
+	}
+	if string(got.AppKeys) != string(want.AppKeys) {
+		t.Fatalf("got AppKeys %q, want %q", got.AppKeys, want.AppKeys)
+	}
+}
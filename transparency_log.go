@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// merkleTransparencyLog turns the flat, human-readable app-hash log that
+// transparencyLogHandler used to print into a proper RFC 6962-style
+// append-only Merkle log: leaf hashes are SHA-256(0x00 || entry), inner
+// nodes are SHA-256(0x01 || left || right). This lets clients verify not
+// just "this enclave ran image X" but also "image X was publicly logged
+// before I trusted it", which closes the split-view attack that a flat log
+// is vulnerable to: a malicious host could otherwise show different clients
+// different, inconsistent logs.
+type merkleTransparencyLog struct {
+	mu       sync.RWMutex
+	leaves   [][]byte
+	cache    Cache
+	cacheKey string
+	signer   crypto.Signer
+}
+
+// newMerkleTransparencyLog returns a merkleTransparencyLog that persists its
+// leaves through cache under cacheKey, so the log survives enclave restarts,
+// and signs tree heads with signer (the enclave's long-term key). Leaves
+// found in the cache under cacheKey are restored on construction.
+func newMerkleTransparencyLog(cache Cache, cacheKey string, signer crypto.Signer) *merkleTransparencyLog {
+	l := &merkleTransparencyLog{cache: cache, cacheKey: cacheKey, signer: signer}
+	if cache != nil {
+		raw, err := cache.Get(context.Background(), cacheKey)
+		if err == nil {
+			if err := json.Unmarshal(raw, &l.leaves); err != nil {
+				elog.Printf("Failed to unmarshal cached transparency log: %s", err)
+			}
+		} else if err != errCacheMiss {
+			elog.Printf("Failed to load transparency log from cache: %s", err)
+		}
+	}
+	return l
+}
+
+// leafHash implements RFC 6962's MTH for a single leaf: SHA-256(0x00 || d).
+func leafHash(entry []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(entry)
+	return h.Sum(nil)
+}
+
+// innerHash implements RFC 6962's MTH for an internal node:
+// SHA-256(0x01 || left || right).
+func innerHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Append adds entry (typically a freshly-deployed app's hash) to the log and
+// persists the updated leaf set through the cache.
+func (l *merkleTransparencyLog) Append(entry []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.leaves = append(l.leaves, append([]byte(nil), entry...))
+	return l.persist()
+}
+
+func (l *merkleTransparencyLog) persist() error {
+	if l.cache == nil {
+		return nil
+	}
+	raw, err := json.Marshal(l.leaves)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transparency log: %w", err)
+	}
+	return l.cache.Put(context.Background(), l.cacheKey, raw)
+}
+
+// STH is a signed tree head, as defined by RFC 6962 ยง3.5/ยง3.6: a commitment
+// to the log's current size and root hash that clients can use to verify
+// inclusion and consistency proofs against.
+type STH struct {
+	TreeSize  int64     `json:"tree_size"`
+	RootHash  []byte    `json:"root_hash"`
+	Timestamp time.Time `json:"timestamp"`
+	Signature []byte    `json:"signature"`
+}
+
+// Serialize returns a deterministic byte encoding of the STH suitable for
+// embedding in an attestation document's user_data.
+func (s *STH) Serialize() []byte {
+	raw, _ := json.Marshal(s)
+	return raw
+}
+
+// merkleRoot computes the RFC 6962 Merkle Tree Hash of leaves[lo:hi).
+func merkleRoot(leaves [][]byte, lo, hi int) []byte {
+	n := hi - lo
+	switch {
+	case n == 0:
+		return sha256.New().Sum(nil) // MTH({}) is the hash of the empty string.
+	case n == 1:
+		return leafHash(leaves[lo])
+	default:
+		k := largestPowerOfTwoLessThan(n)
+		left := merkleRoot(leaves, lo, lo+k)
+		right := merkleRoot(leaves, lo+k, hi)
+		return innerHash(left, right)
+	}
+}
+
+// largestPowerOfTwoLessThan returns the largest power of two strictly less
+// than n, as required by RFC 6962's split point k.
+func largestPowerOfTwoLessThan(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+// SignedTreeHead returns the log's current STH, signed with the log's
+// configured signer.
+func (l *merkleTransparencyLog) SignedTreeHead() (*STH, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	sth := &STH{
+		TreeSize:  int64(len(l.leaves)),
+		RootHash:  merkleRoot(l.leaves, 0, len(l.leaves)),
+		Timestamp: currentTime(),
+	}
+
+	toSign := sha256.Sum256(sth.Serialize())
+	sig, err := l.signer.Sign(rand.Reader, toSign[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tree head: %w", err)
+	}
+	sth.Signature = sig
+	return sth, nil
+}
+
+// InclusionProof returns the leaf index of entry and the RFC 6962 audit
+// path (sibling hashes) that proves entry is included in the log's current
+// tree.
+func (l *merkleTransparencyLog) InclusionProof(entry []byte) (int64, [][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	target := leafHash(entry)
+	idx := -1
+	for i, e := range l.leaves {
+		if string(leafHash(e)) == string(target) {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return 0, nil, fmt.Errorf("entry not found in transparency log")
+	}
+
+	path := auditPath(l.leaves, 0, len(l.leaves), idx)
+	return int64(idx), path, nil
+}
+
+// auditPath recursively computes the RFC 6962 audit path for leaf index idx
+// within leaves[lo:hi).
+func auditPath(leaves [][]byte, lo, hi, idx int) [][]byte {
+	n := hi - lo
+	if n <= 1 {
+		return nil
+	}
+	k := largestPowerOfTwoLessThan(n)
+	if idx-lo < k {
+		return append(auditPath(leaves, lo, lo+k, idx), merkleRoot(leaves, lo+k, hi))
+	}
+	return append(auditPath(leaves, lo+k, hi, idx), merkleRoot(leaves, lo, lo+k))
+}
+
+// ConsistencyProof returns the RFC 6962 consistency proof between two
+// earlier tree sizes, `from` and `to`, so a client can verify that the log
+// only ever appended entries between the two STHs it has observed.
+func (l *merkleTransparencyLog) ConsistencyProof(from, to int64) ([][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if from < 0 || to > int64(len(l.leaves)) || from > to {
+		return nil, fmt.Errorf("invalid tree size range [%d, %d)", from, to)
+	}
+	if from == 0 || from == to {
+		return nil, nil
+	}
+	return subProof(l.leaves, 0, int(to), int(from), true), nil
+}
+
+// subProof implements RFC 6962's SUBPROOF algorithm.
+func subProof(leaves [][]byte, lo, hi, m int, haveRoot bool) [][]byte {
+	n := hi - lo
+	if m == n {
+		if haveRoot {
+			return nil
+		}
+		return [][]byte{merkleRoot(leaves, lo, hi)}
+	}
+	k := largestPowerOfTwoLessThan(n)
+	if m <= k {
+		return append(subProof(leaves, lo, lo+k, m, haveRoot), merkleRoot(leaves, lo+k, hi))
+	}
+	proof := subProof(leaves, lo+k, hi, m-k, false)
+	return append(proof, merkleRoot(leaves, lo, lo+k))
+}
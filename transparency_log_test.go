@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+)
+
+// nilContextPanicsCache is a Cache that panics if it's ever called with a
+// nil context, mimicking what the real AWS SDK transport does. It catches
+// regressions of the bug where Append used to pass a literal nil instead of
+// context.Background().
+type nilContextPanicsCache struct {
+	memoryCache
+}
+
+func (c *nilContextPanicsCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if ctx == nil {
+		panic("Get called with a nil context")
+	}
+	return c.memoryCache.Get(ctx, key)
+}
+
+func (c *nilContextPanicsCache) Put(ctx context.Context, key string, data []byte) error {
+	if ctx == nil {
+		panic("Put called with a nil context")
+	}
+	return c.memoryCache.Put(ctx, key, data)
+}
+
+func (c *nilContextPanicsCache) Delete(ctx context.Context, key string) error {
+	if ctx == nil {
+		panic("Delete called with a nil context")
+	}
+	return c.memoryCache.Delete(ctx, key)
+}
+
+func newNilContextPanicsCache() *nilContextPanicsCache {
+	return &nilContextPanicsCache{memoryCache: *newMemoryCache()}
+}
+
+func TestAppendDoesNotPassANilContextToTheCache(t *testing.T) {
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate signer key: %s", err)
+	}
+	log := newMerkleTransparencyLog(newNilContextPanicsCache(), "transparency-log", signer)
+
+	if err := log.Append([]byte("app-v1")); err != nil {
+		t.Fatalf("failed to append entry: %s", err)
+	}
+}
+
+func newTestLog(t *testing.T, entries ...string) *merkleTransparencyLog {
+	t.Helper()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate signer key: %s", err)
+	}
+	log := newMerkleTransparencyLog(nil, "", signer)
+	for _, e := range entries {
+		if err := log.Append([]byte(e)); err != nil {
+			t.Fatalf("failed to append entry: %s", err)
+		}
+	}
+	return log
+}
+
+func TestSignedTreeHeadGrowsWithAppends(t *testing.T) {
+	log := newTestLog(t, "app-v1", "app-v2")
+
+	sth, err := log.SignedTreeHead()
+	if err != nil {
+		t.Fatalf("failed to get signed tree head: %s", err)
+	}
+	if sth.TreeSize != 2 {
+		t.Fatalf("expected tree size 2, got %d", sth.TreeSize)
+	}
+	if len(sth.Signature) == 0 {
+		t.Fatal("expected a non-empty signature")
+	}
+}
+
+func TestInclusionProofFindsAppendedEntry(t *testing.T) {
+	log := newTestLog(t, "app-v1", "app-v2", "app-v3")
+
+	idx, path, err := log.InclusionProof([]byte("app-v2"))
+	if err != nil {
+		t.Fatalf("failed to get inclusion proof: %s", err)
+	}
+	if idx != 1 {
+		t.Fatalf("expected leaf index 1, got %d", idx)
+	}
+	if len(path) == 0 {
+		t.Fatal("expected a non-empty audit path for a 3-leaf tree")
+	}
+}
+
+func TestInclusionProofRejectsUnknownEntry(t *testing.T) {
+	log := newTestLog(t, "app-v1")
+
+	if _, _, err := log.InclusionProof([]byte("never-logged")); err == nil {
+		t.Fatal("expected an error for an entry that was never logged")
+	}
+}
+
+func TestConsistencyProofBetweenTreeSizes(t *testing.T) {
+	log := newTestLog(t, "app-v1", "app-v2", "app-v3")
+
+	proof, err := log.ConsistencyProof(1, 3)
+	if err != nil {
+		t.Fatalf("failed to get consistency proof: %s", err)
+	}
+	if len(proof) == 0 {
+		t.Fatal("expected a non-empty consistency proof")
+	}
+}
+
+func TestMerkleRootIsDeterministic(t *testing.T) {
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	root1 := merkleRoot(leaves, 0, len(leaves))
+	root2 := merkleRoot(leaves, 0, len(leaves))
+	if !bytes.Equal(root1, root2) {
+		t.Fatal("expected merkleRoot to be deterministic")
+	}
+}
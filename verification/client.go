@@ -0,0 +1,60 @@
+package verification
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Client lets nitriding-internal callers delegate attestation verification
+// to a remote Service instead of verifying documents locally. Its method
+// mirrors the shape of nitriding's internal attester interface, so callers
+// can switch between a local nitroAttester and a remote Client with minimal
+// changes.
+type Client struct {
+	// BaseURL is the verification service's base URL, e.g.
+	// "https://verify.example.com".
+	BaseURL string
+	// Policy is the name of the policy that the remote service should check
+	// submitted documents against.
+	Policy string
+	// HTTPClient is used to make requests. If nil, http.DefaultClient is
+	// used.
+	HTTPClient *http.Client
+}
+
+// VerifyAttstn submits doc to the remote verification service and returns
+// its verdict.
+func (c *Client) VerifyAttstn(doc []byte, expectedNonce string) (*Verdict, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	reqBody, err := json.Marshal(verifyRequest{
+		Document:      base64.StdEncoding.EncodeToString(doc),
+		ExpectedNonce: expectedNonce,
+		Policy:        c.Policy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal verification request: %w", err)
+	}
+
+	resp, err := httpClient.Post(c.BaseURL+"/verify", "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach verification service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("verification service returned status %d", resp.StatusCode)
+	}
+
+	var verdict Verdict
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return nil, fmt.Errorf("failed to parse verification response: %w", err)
+	}
+	return &verdict, nil
+}
@@ -0,0 +1,70 @@
+// Package verification implements a standalone attestation-verification
+// service that lets third-party clients verify AWS Nitro Enclave attestation
+// documents over HTTP, without linking github.com/hf/nitrite themselves.
+//
+// The service wraps the same verification logic that nitriding's internal
+// nitroAttester.verifyAttstn relies on, and exposes it as a POST /verify
+// endpoint that accepts a base64-encoded attestation document, an optional
+// expected nonce, and the name of a policy to check the document against.
+// This mirrors the Constellation-style split of "verifier" from "attester":
+// nitriding-internal callers keep attesting locally, while remote clients
+// (mobile apps, browsers) can verify without embedding any CBOR/COSE code.
+package verification
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Policy describes the constraints that an attestation document must satisfy
+// to be considered valid for a particular enclave image.
+type Policy struct {
+	// Name identifies the policy. A single verification service deployment
+	// can serve many named policies, so that it can front many enclave
+	// images at once.
+	Name string `json:"name"`
+	// PCR0, PCR1, PCR2, and PCR8 list the acceptable hex-encoded PCR values.
+	// A document passes a check if its PCR matches one of the listed
+	// values. An empty list means the PCR isn't constrained.
+	PCR0 []string `json:"pcr0"`
+	PCR1 []string `json:"pcr1"`
+	PCR2 []string `json:"pcr2"`
+	PCR8 []string `json:"pcr8"`
+	// MaxDocumentAge bounds how old (in seconds) the COSE signature's
+	// timestamp may be before the document is rejected.
+	MaxDocumentAge int64 `json:"max_document_age_secs"`
+	// RootCertFingerprint is the expected hex-encoded SHA-256 fingerprint of
+	// the AWS Nitro root certificate that signed the document's cert chain.
+	RootCertFingerprint string `json:"root_cert_fingerprint"`
+}
+
+// PolicySet maps policy names to their Policy, which lets one verification
+// service front many enclave images.
+type PolicySet map[string]*Policy
+
+// LoadPolicies reads a JSON-encoded list of policies from the file at path
+// and returns them indexed by name.
+func LoadPolicies(path string) (PolicySet, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var policies []*Policy
+	if err := json.Unmarshal(raw, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+
+	set := make(PolicySet, len(policies))
+	for _, p := range policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("policy file contains a policy without a name")
+		}
+		if _, exists := set[p.Name]; exists {
+			return nil, fmt.Errorf("duplicate policy name %q", p.Name)
+		}
+		set[p.Name] = p
+	}
+	return set, nil
+}
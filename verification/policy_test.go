@@ -0,0 +1,55 @@
+package verification
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPolicies(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policies.json")
+	data := `[
+		{"name": "prod", "pcr0": ["abcd"]},
+		{"name": "staging", "pcr0": ["1234"]}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write policy file: %s", err)
+	}
+
+	policies, err := LoadPolicies(path)
+	if err != nil {
+		t.Fatalf("failed to load policies: %s", err)
+	}
+	if len(policies) != 2 {
+		t.Fatalf("expected 2 policies, got %d", len(policies))
+	}
+	if policies["prod"].PCR0[0] != "abcd" {
+		t.Fatalf("unexpected PCR0 value for policy 'prod'")
+	}
+}
+
+func TestLoadPoliciesRejectsDuplicateNames(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policies.json")
+	data := `[{"name": "prod"}, {"name": "prod"}]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write policy file: %s", err)
+	}
+
+	if _, err := LoadPolicies(path); err == nil {
+		t.Fatal("expected an error for duplicate policy names")
+	}
+}
+
+func TestPCRAllowed(t *testing.T) {
+	if !pcrAllowed(nil, "anything") {
+		t.Fatal("an empty policy list should allow any PCR value")
+	}
+	if !pcrAllowed([]string{"abcd"}, "abcd") {
+		t.Fatal("expected PCR value to be allowed")
+	}
+	if pcrAllowed([]string{"abcd"}, "ffff") {
+		t.Fatal("expected PCR value to be rejected")
+	}
+}
@@ -0,0 +1,170 @@
+package verification
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hf/nitrite"
+)
+
+// now is a seam for tests to pin "the current time" without waiting on the
+// real clock; production code always uses time.Now.
+var now = time.Now
+
+// Verdict is the structured result of verifying an attestation document
+// against a Policy.
+type Verdict struct {
+	Valid     bool              `json:"valid"`
+	PCRs      map[string]string `json:"pcrs,omitempty"`
+	UserData  []byte            `json:"user_data,omitempty"`
+	PublicKey []byte            `json:"public_key,omitempty"`
+	// MeasuredBootID identifies the specific enclave instance that produced
+	// the attestation document (the Nitro module ID, e.g.
+	// "i-0123...-enc0123..."), distinct from PCRs, which identify the
+	// enclave's measured image rather than the running instance.
+	MeasuredBootID string   `json:"measured_boot_id,omitempty"`
+	Reasons        []string `json:"reasons,omitempty"`
+}
+
+// verifyRequest is the JSON body that POST /verify expects.
+type verifyRequest struct {
+	Document      string `json:"document"`
+	ExpectedNonce string `json:"expected_nonce,omitempty"`
+	Policy        string `json:"policy"`
+}
+
+// Service exposes attestation verification as a standalone HTTP endpoint, so
+// that clients who don't want to link github.com/hf/nitrite can verify
+// attestation documents by POSTing to /verify instead.
+type Service struct {
+	policies PolicySet
+}
+
+// NewService returns a Service that verifies submitted attestation documents
+// against the given set of named policies.
+func NewService(policies PolicySet) *Service {
+	return &Service{policies: policies}
+}
+
+// ServeHTTP implements the POST /verify endpoint. It decodes the submitted
+// attestation document, verifies it against the requested policy, and writes
+// back a JSON-encoded Verdict.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req verifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	policy, ok := s.policies[req.Policy]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown policy %q", req.Policy), http.StatusBadRequest)
+		return
+	}
+
+	doc, err := base64.StdEncoding.DecodeString(req.Document)
+	if err != nil {
+		http.Error(w, "attestation document is not valid base64", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verify(doc, policy, req.ExpectedNonce))
+}
+
+// verify runs nitrite's attestation verification and checks the result
+// against policy, returning a Verdict that explains why verification failed,
+// if it did.
+func verify(doc []byte, policy *Policy, expectedNonce string) *Verdict {
+	res, err := nitrite.Verify(doc, nitrite.VerifyOptions{})
+	if err != nil {
+		return &Verdict{Valid: false, Reasons: []string{fmt.Sprintf("attestation verification failed: %s", err)}}
+	}
+
+	var rootDER []byte
+	if len(res.Certificates) > 0 {
+		rootDER = res.Certificates[len(res.Certificates)-1].Raw
+	}
+
+	pcrs := make(map[string]string, len(res.Document.PCRs))
+	for i, pcr := range res.Document.PCRs {
+		pcrs[fmt.Sprint(i)] = fmt.Sprintf("%x", pcr)
+	}
+
+	reasons := checkPolicy(policy, pcrs, res.Document.Timestamp, rootDER, res.Document.Nonce, expectedNonce)
+
+	return &Verdict{
+		Valid:          len(reasons) == 0,
+		PCRs:           pcrs,
+		UserData:       res.Document.UserData,
+		PublicKey:      res.Document.PublicKey,
+		MeasuredBootID: res.Document.ModuleID,
+		Reasons:        reasons,
+	}
+}
+
+// checkPolicy holds the policy checks that don't require a real attestation
+// document to exercise, so they can be unit tested directly: PCR allowlists,
+// the nonce, the document's age, and the root certificate's fingerprint.
+// timestampMillis is milliseconds since the Unix epoch, as carried in the
+// attestation document; rootDER is the DER-encoded root certificate that
+// nitrite verified the document's certificate chain against.
+func checkPolicy(policy *Policy, pcrs map[string]string, timestampMillis uint64, rootDER, docNonce []byte, expectedNonce string) []string {
+	var reasons []string
+
+	if !pcrAllowed(policy.PCR0, pcrs["0"]) {
+		reasons = append(reasons, "PCR0 not allowed by policy")
+	}
+	if !pcrAllowed(policy.PCR1, pcrs["1"]) {
+		reasons = append(reasons, "PCR1 not allowed by policy")
+	}
+	if !pcrAllowed(policy.PCR2, pcrs["2"]) {
+		reasons = append(reasons, "PCR2 not allowed by policy")
+	}
+	if !pcrAllowed(policy.PCR8, pcrs["8"]) {
+		reasons = append(reasons, "PCR8 not allowed by policy")
+	}
+	if expectedNonce != "" && base64.StdEncoding.EncodeToString(docNonce) != expectedNonce {
+		reasons = append(reasons, "nonce does not match expected_nonce")
+	}
+
+	if policy.MaxDocumentAge > 0 {
+		docTime := time.UnixMilli(int64(timestampMillis))
+		if age := now().Sub(docTime); age > time.Duration(policy.MaxDocumentAge)*time.Second {
+			reasons = append(reasons, fmt.Sprintf("document is %s old, exceeding the policy's max_document_age_secs of %ds", age, policy.MaxDocumentAge))
+		}
+	}
+
+	if policy.RootCertFingerprint != "" {
+		fingerprint := sha256.Sum256(rootDER)
+		if hex.EncodeToString(fingerprint[:]) != policy.RootCertFingerprint {
+			reasons = append(reasons, "root certificate fingerprint does not match policy's root_cert_fingerprint")
+		}
+	}
+
+	return reasons
+}
+
+// pcrAllowed reports whether value is acceptable under a policy's PCR list.
+// An empty list means the PCR isn't constrained.
+func pcrAllowed(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package verification
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestCheckPolicyRejectsStaleDocuments(t *testing.T) {
+	fixed := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	old := now
+	now = func() time.Time { return fixed }
+	defer func() { now = old }()
+
+	policy := &Policy{MaxDocumentAge: 60} // 1 minute
+	staleTimestamp := uint64(fixed.Add(-2 * time.Minute).UnixMilli())
+
+	reasons := checkPolicy(policy, nil, staleTimestamp, nil, nil, "")
+	if len(reasons) == 0 {
+		t.Fatal("expected a stale document to be rejected")
+	}
+}
+
+func TestCheckPolicyAcceptsFreshDocuments(t *testing.T) {
+	fixed := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	old := now
+	now = func() time.Time { return fixed }
+	defer func() { now = old }()
+
+	policy := &Policy{MaxDocumentAge: 300} // 5 minutes
+	freshTimestamp := uint64(fixed.Add(-10 * time.Second).UnixMilli())
+
+	reasons := checkPolicy(policy, nil, freshTimestamp, nil, nil, "")
+	if len(reasons) != 0 {
+		t.Fatalf("expected a fresh document to pass, got reasons: %v", reasons)
+	}
+}
+
+func TestCheckPolicyRejectsWrongRootFingerprint(t *testing.T) {
+	root := []byte("pretend this is a DER-encoded root certificate")
+	policy := &Policy{RootCertFingerprint: "0000000000000000000000000000000000000000000000000000000000000000"}
+
+	reasons := checkPolicy(policy, nil, 0, root, nil, "")
+	if len(reasons) == 0 {
+		t.Fatal("expected an unexpected root certificate to be rejected")
+	}
+}
+
+func TestCheckPolicyAcceptsMatchingRootFingerprint(t *testing.T) {
+	root := []byte("pretend this is a DER-encoded root certificate")
+	sum := sha256.Sum256(root)
+	policy := &Policy{RootCertFingerprint: hex.EncodeToString(sum[:])}
+
+	reasons := checkPolicy(policy, nil, 0, root, nil, "")
+	if len(reasons) != 0 {
+		t.Fatalf("expected the matching root certificate to pass, got reasons: %v", reasons)
+	}
+}